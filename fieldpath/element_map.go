@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+// pathElementKind orders the mutually-exclusive forms a PathElement can
+// take, so that two PathElements of different forms still compare
+// consistently.
+type pathElementKind int
+
+const (
+	fieldNameKind pathElementKind = iota
+	keyKind
+	valueKind
+	indexKind
+	unsetKind
+)
+
+func kindOf(pe PathElement) pathElementKind {
+	switch {
+	case pe.FieldName != nil:
+		return fieldNameKind
+	case pe.Key != nil:
+		return keyKind
+	case pe.Value != nil:
+		return valueKind
+	case pe.Index != nil:
+		return indexKind
+	default:
+		return unsetKind
+	}
+}
+
+// comparePathElements structurally orders a and b. Unlike comparing
+// pe.String() output, this can't be fooled by two different Value path
+// elements that happen to render to the same string.
+func comparePathElements(a, b PathElement) int {
+	ak, bk := kindOf(a), kindOf(b)
+	if ak != bk {
+		if ak < bk {
+			return -1
+		}
+		return 1
+	}
+
+	switch ak {
+	case fieldNameKind:
+		return strings.Compare(*a.FieldName, *b.FieldName)
+	case keyKind:
+		return compareFields(a.Key, b.Key)
+	case valueKind:
+		return value.Compare(*a.Value, *b.Value)
+	case indexKind:
+		switch {
+		case *a.Index < *b.Index:
+			return -1
+		case *a.Index > *b.Index:
+			return 1
+		default:
+			return 0
+		}
+	default:
+		return 0
+	}
+}
+
+// compareFields orders two sets of key fields lexicographically by name,
+// then by value.
+func compareFields(a, b []value.Field) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i].Name, b[i].Name); c != 0 {
+			return c
+		}
+		if c := value.Compare(a[i].Value, b[i].Value); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// PathElementMap is a map from PathElement to an arbitrary value, ordered by
+// a structural comparison of the PathElement (as opposed to keying off
+// PathElement.String(), whose output is not a guaranteed injection for
+// Value path elements). It is backed by a sorted slice rather than a Go map
+// since PathElement is not comparable/hashable in the general case.
+type PathElementMap struct {
+	members []pathElementMapItem
+}
+
+type pathElementMapItem struct {
+	pe    PathElement
+	value interface{}
+}
+
+// search returns the index at which pe is found, or the index at which it
+// would need to be inserted to keep p.members sorted, and whether it was
+// found.
+func (p *PathElementMap) search(pe PathElement) (int, bool) {
+	i := sort.Search(len(p.members), func(i int) bool {
+		return comparePathElements(p.members[i].pe, pe) >= 0
+	})
+	if i < len(p.members) && comparePathElements(p.members[i].pe, pe) == 0 {
+		return i, true
+	}
+	return i, false
+}
+
+// Insert sets the value associated with pe, inserting a new entry if pe is
+// not already present.
+func (p *PathElementMap) Insert(pe PathElement, val interface{}) {
+	i, found := p.search(pe)
+	if found {
+		p.members[i].value = val
+		return
+	}
+	p.members = append(p.members, pathElementMapItem{})
+	copy(p.members[i+1:], p.members[i:])
+	p.members[i] = pathElementMapItem{pe: pe, value: val}
+}
+
+// Get returns the value associated with pe, and whether it was present.
+func (p *PathElementMap) Get(pe PathElement) (interface{}, bool) {
+	i, found := p.search(pe)
+	if !found {
+		return nil, false
+	}
+	return p.members[i].value, true
+}
+
+// Delete removes pe from the map, if present.
+func (p *PathElementMap) Delete(pe PathElement) {
+	i, found := p.search(pe)
+	if !found {
+		return
+	}
+	p.members = append(p.members[:i], p.members[i+1:]...)
+}
+
+// Len returns the number of entries in the map.
+func (p *PathElementMap) Len() int {
+	return len(p.members)
+}
+
+// Iterate calls fn once for each entry, in ascending PathElement order. It
+// stops early if fn returns false.
+func (p *PathElementMap) Iterate(fn func(PathElement, interface{}) bool) {
+	for _, item := range p.members {
+		if !fn(item.pe, item.value) {
+			return
+		}
+	}
+}
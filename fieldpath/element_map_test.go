@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+func fieldNamePE(name string) PathElement {
+	return PathElement{FieldName: &name}
+}
+
+func TestPathElementMapInsertGetDelete(t *testing.T) {
+	var m PathElementMap
+
+	a, b := fieldNamePE("a"), fieldNamePE("b")
+	m.Insert(a, 1)
+	m.Insert(b, 2)
+
+	if v, ok := m.Get(a); !ok || v.(int) != 1 {
+		t.Fatalf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	if got := m.Len(); got != 2 {
+		t.Fatalf("Len() = %v, want 2", got)
+	}
+
+	m.Insert(a, 3)
+	if v, ok := m.Get(a); !ok || v.(int) != 3 {
+		t.Fatalf("Get(a) after overwrite = %v, %v; want 3, true", v, ok)
+	}
+	if got := m.Len(); got != 2 {
+		t.Fatalf("Len() after overwrite = %v, want 2", got)
+	}
+
+	m.Delete(a)
+	if _, ok := m.Get(a); ok {
+		t.Fatalf("Get(a) found after Delete")
+	}
+	if got := m.Len(); got != 1 {
+		t.Fatalf("Len() after Delete = %v, want 1", got)
+	}
+}
+
+func TestPathElementMapValueKeysDontCollideByString(t *testing.T) {
+	// Two distinct Value path elements that render identically (IntValue(1)
+	// and FloatValue(1) both print as "1" via HumanReadable) must still be
+	// treated as distinct keys, since comparePathElements is structural.
+	v1 := value.IntValue(1)
+	v2 := value.FloatValue(1)
+	var m PathElementMap
+	m.Insert(PathElement{Value: &v1}, "one")
+	m.Insert(PathElement{Value: &v2}, "two")
+
+	if got := m.Len(); got != 2 {
+		t.Fatalf("Len() = %v, want 2", got)
+	}
+	got, ok := m.Get(PathElement{Value: &v1})
+	if !ok || got.(string) != "one" {
+		t.Fatalf("Get(v1) = %v, %v; want one, true", got, ok)
+	}
+}
+
+func TestPathElementMapOrderedIteration(t *testing.T) {
+	var m PathElementMap
+	m.Insert(fieldNamePE("c"), nil)
+	m.Insert(fieldNamePE("a"), nil)
+	m.Insert(fieldNamePE("b"), nil)
+
+	var order []string
+	m.Iterate(func(pe PathElement, _ interface{}) bool {
+		order = append(order, *pe.FieldName)
+		return true
+	})
+	want := []string{"a", "b", "c"}
+	if len(order) != len(want) {
+		t.Fatalf("Iterate order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Iterate order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestPathElementValueMap(t *testing.T) {
+	var m PathElementValueMap
+	pe := fieldNamePE("a")
+	m.Insert(pe, value.IntValue(1))
+
+	got, ok := m.Get(pe)
+	if !ok {
+		t.Fatalf("Get(a) not found")
+	}
+	if got.Int == nil || *got.Int != 1 {
+		t.Fatalf("Get(a) = %v, want IntValue(1)", got)
+	}
+
+	m.Delete(pe)
+	if _, ok := m.Get(pe); ok {
+		t.Fatalf("Get(a) found after Delete")
+	}
+}
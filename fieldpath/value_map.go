@@ -0,0 +1,61 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fieldpath
+
+import (
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+// PathElementValueMap is a map from PathElement to value.Value, keyed by a
+// structural comparison of the PathElement rather than its string encoding.
+// It is built on top of PathElementMap so that the comparison logic lives in
+// exactly one place.
+type PathElementValueMap struct {
+	m PathElementMap
+}
+
+// Insert sets the value associated with pe.
+func (s *PathElementValueMap) Insert(pe PathElement, val value.Value) {
+	s.m.Insert(pe, val)
+}
+
+// Get returns the value associated with pe, and whether it was present.
+func (s *PathElementValueMap) Get(pe PathElement) (value.Value, bool) {
+	v, ok := s.m.Get(pe)
+	if !ok {
+		return value.Value{}, false
+	}
+	return v.(value.Value), true
+}
+
+// Delete removes pe from the map, if present.
+func (s *PathElementValueMap) Delete(pe PathElement) {
+	s.m.Delete(pe)
+}
+
+// Len returns the number of entries in the map.
+func (s *PathElementValueMap) Len() int {
+	return s.m.Len()
+}
+
+// Iterate calls fn once for each entry, in ascending PathElement order. It
+// stops early if fn returns false.
+func (s *PathElementValueMap) Iterate(fn func(PathElement, value.Value) bool) {
+	s.m.Iterate(func(pe PathElement, val interface{}) bool {
+		return fn(pe, val.(value.Value))
+	})
+}
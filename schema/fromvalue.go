@@ -17,101 +17,316 @@ limitations under the License.
 package schema
 
 import (
+	"sigs.k8s.io/structured-merge-diff/fieldpath"
 	"sigs.k8s.io/structured-merge-diff/value"
 )
 
-// SetFromValue creates a set containing every leaf field mentioned in v.
-func SetFromValue(name string, v value.Value) *Set {
-	atom := atomFor(v)
-	typeDef := TypeDef{
-		Name: name,
-		Atom: atom,
-	}
-	s := Schema{
-		Types: []TypeDef{typeDef},
+// ListGuesser decides, in the absence of a real schema, how the elements of
+// a list value relate to one another and how each element should be
+// addressed by a PathElement. Implementations may inspect every item in the
+// list (not just the one currently being addressed) since some heuristics,
+// like a composite key, can only be determined by looking at the whole
+// list.
+type ListGuesser interface {
+	// GuessListRelationship decides whether items should be treated as an
+	// Associative list/set or an Atomic one, and, if Associative and keyed,
+	// which field names make up the key.
+	GuessListRelationship(items []value.Value) (relationship ElementRelationship, keys []string)
+	// GuessItemPathElement returns the PathElement that should address
+	// item, which is at position index in a list for which
+	// GuessListRelationship returned keys.
+	GuessItemPathElement(index int, item value.Value, keys []string) fieldpath.PathElement
+}
+
+// AssociativeListCandidateFieldNames lists the field names which
+// DefaultListGuesser considers keys if found in a list element.
+var AssociativeListCandidateFieldNames = []string{
+	"key",
+	"id",
+	"name",
+}
+
+// DefaultListGuesser reproduces the guesser's original, simplest behavior:
+// a map item is addressed by key, using whichever of CandidateFieldNames it
+// has scalar values for; anything else falls back to being addressed by
+// index. Its GuessListRelationship always reports Atomic with no keys.
+type DefaultListGuesser struct {
+	// CandidateFieldNames overrides AssociativeListCandidateFieldNames for
+	// this guesser. Nil means "use the package default".
+	CandidateFieldNames []string
+}
+
+func (g DefaultListGuesser) candidateFieldNames() []string {
+	if g.CandidateFieldNames != nil {
+		return g.CandidateFieldNames
 	}
-	return s
+	return AssociativeListCandidateFieldNames
 }
 
-func (w *objectWalker) atomFor(v value.Value) {
-	switch {
-	case v.Null:
-		return Atom{Untyped: &Untyped{}}
+// GuessListRelationship implements ListGuesser.
+func (DefaultListGuesser) GuessListRelationship(items []value.Value) (ElementRelationship, []string) {
+	return Atomic, nil
+}
 
-	case v.FloatValue != nil:
-		return Atom{Scalar: &Numeric}
+// GuessItemPathElement implements ListGuesser.
+func (g DefaultListGuesser) GuessItemPathElement(index int, item value.Value, keys []string) fieldpath.PathElement {
+	if item.Map == nil {
+		// Non map items could be parts of sets or regular "atomic"
+		// lists. We won't try to guess whether something should be a
+		// set or not.
+		return fieldpath.PathElement{Index: &index}
+	}
 
-	case v.IntValue != nil:
-		return Atom{Scalar: &Numeric}
+	var found []value.Field
+	for _, name := range g.candidateFieldNames() {
+		f, ok := item.Map.Get(name)
+		if !ok {
+			continue
+		}
+		// only accept primitive/scalar types as keys.
+		if f.Value.Null || f.Value.Map != nil || f.Value.List != nil {
+			continue
+		}
+		found = append(found, *f)
+	}
+	if len(found) > 0 {
+		return fieldpath.PathElement{Key: found}
+	}
+	return fieldpath.PathElement{Index: &index}
+}
 
-	case v.StringValue != nil:
-		return Atom{Scalar: &String}
+// CompositeKeyGuesser scans every item in a list up front, looking for the
+// smallest subset of scalar-valued fields that, taken together, is unique
+// across every item. If it finds one, the list is treated as Associative
+// and keyed by those fields. If items aren't maps at all and
+// DetectPrimitiveSets is on, it instead checks whether the primitives
+// themselves are already unique and, if so, treats the list as an
+// Associative set. Anything that clears neither bar falls back to
+// DefaultListGuesser, which still addresses a map item with a recognizable
+// "key"/"id"/"name"-style field (or caller-supplied CandidateFieldNames) by
+// that key - it is only guaranteed to fall back to Atomic, index-only
+// addressing for items DefaultListGuesser itself can't find a candidate
+// field on.
+//
+// GuessListRelationship signals "Associative set of primitives, no named
+// key fields" by returning a non-nil, empty keys slice, as opposed to nil
+// for "not associative at all". GuessItemPathElement relies on that
+// distinction to tell the two cases apart.
+type CompositeKeyGuesser struct {
+	// MaxKeyFields bounds how large a composite key we'll search for. A
+	// search over all subsets is exponential in the number of candidate
+	// fields, so this should stay small. Zero means "use a sane default"
+	// (3).
+	MaxKeyFields int
 
-	case v.BooleanValue != nil:
-		return Atom{Scalar: &Boolean}
+	// DetectPrimitiveSets opts into treating a list of scalars with no
+	// duplicates as an Associative set, rather than always falling back
+	// to an Atomic, index-addressed list. Off by default because a set
+	// interpretation changes merge semantics (whole-item ownership
+	// instead of whole-list ownership), which isn't safe to assume
+	// without the caller asking for it.
+	DetectPrimitiveSets bool
+}
 
-	// Descend
-	case v.ListValue != nil:
-		// If the list were atomic, we'd break here, but we don't have
-		// a schema, so we can't tell.
+func (g CompositeKeyGuesser) maxKeyFields() int {
+	if g.MaxKeyFields > 0 {
+		return g.MaxKeyFields
+	}
+	return 3
+}
 
-		for i, child := range w.value.ListValue.Items {
-			w2 := *w
-			w2.path = append(w.path, GuessBestListPathElement(i, child))
-			w2.value = child
-			w2.walk()
+// GuessListRelationship implements ListGuesser.
+func (g CompositeKeyGuesser) GuessListRelationship(items []value.Value) (ElementRelationship, []string) {
+	if candidates := commonScalarFields(items); candidates != nil {
+		for size := 1; size <= len(candidates) && size <= g.maxKeyFields(); size++ {
+			if keys := firstUniqueSubset(items, candidates, size); keys != nil {
+				return Associative, keys
+			}
 		}
-		return
-	case v.MapValue != nil:
-		// If the map/struct were atomic, we'd break here, but we don't
-		// have a schema, so we can't tell.
+	}
+	if g.DetectPrimitiveSets && allPrimitivesUnique(items) {
+		return Associative, []string{}
+	}
+	return DefaultListGuesser{}.GuessListRelationship(items)
+}
 
-		for i := range w.value.MapValue.Items {
-			child := w.value.MapValue.Items[i]
-			w2 := *w
-			w2.path = append(w.path, PathElement{FieldName: &child.Name})
-			w2.value = child.Value
-			w2.walk()
+// GuessItemPathElement implements ListGuesser.
+func (g CompositeKeyGuesser) GuessItemPathElement(index int, item value.Value, keys []string) fieldpath.PathElement {
+	switch {
+	case keys == nil:
+		return DefaultListGuesser{}.GuessItemPathElement(index, item, keys)
+	case len(keys) == 0:
+		// An Associative set of primitives: addressed by value (whereas
+		// DefaultListGuesser addresses any non-map item by index).
+		v := item
+		return fieldpath.PathElement{Value: &v}
+	}
+	fields := make([]value.Field, 0, len(keys))
+	for _, name := range keys {
+		f, ok := item.Map.Get(name)
+		if !ok {
+			// Shouldn't happen: keys came from commonScalarFields, which
+			// only returns fields present on every item.
+			return fieldpath.PathElement{Index: &index}
 		}
-		return
+		fields = append(fields, *f)
 	}
+	return fieldpath.PathElement{Key: fields}
 }
 
-// AssociativeListCandidateFieldNames lists the field names which are
-// considered keys if found in a list element.
-var AssociativeListCandidateFieldNames = []string{
-	"key",
-	"id",
-	"name",
+// allPrimitivesUnique reports whether items are all non-map, non-list,
+// non-null values with no duplicates.
+func allPrimitivesUnique(items []value.Value) bool {
+	if len(items) == 0 {
+		return false
+	}
+	seen := map[string]struct{}{}
+	for _, item := range items {
+		if item.Null || item.Map != nil || item.List != nil {
+			return false
+		}
+		key := item.HumanReadable()
+		if _, found := seen[key]; found {
+			return false
+		}
+		seen[key] = struct{}{}
+	}
+	return true
 }
 
-// GuessBestListPathElement guesses whether item is an associative list
-// element, which should be referenced by key(s), or if it is not and therefore
-// referencing by index is acceptable. Currently this is done by checking
-// whether item has any of the fields listed in
-// AssociativeListCandidateFieldNames which have scalar values.
-func GuessBestListPathElement(index int, item value.Value) PathElement {
-	if item.MapValue == nil {
-		// Non map items could be parts of sets or regular "atomic"
-		// lists. We won't try to guess whether something should be a
-		// set or not.
-		return PathElement{Index: &index}
+// commonScalarFields returns the names of the fields that are present, with
+// a scalar (non-null, non-map, non-list) value, on every item in items.
+func commonScalarFields(items []value.Value) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	counts := map[string]int{}
+	var order []string
+	for _, item := range items {
+		if item.Map == nil {
+			return nil
+		}
+		for _, f := range item.Map.Items {
+			if f.Value.Null || f.Value.Map != nil || f.Value.List != nil {
+				continue
+			}
+			if counts[f.Name] == 0 {
+				order = append(order, f.Name)
+			}
+			counts[f.Name]++
+		}
+	}
+	var common []string
+	for _, name := range order {
+		if counts[name] == len(items) {
+			common = append(common, name)
+		}
 	}
+	return common
+}
 
-	var keys []value.Field
-	for _, name := range AssociativeListCandidateFieldNames {
-		f, ok := item.MapValue.Get(name)
-		if !ok {
-			continue
+// firstUniqueSubset returns the first size-element subset of candidates
+// (tried in order) whose values are unique across every item, or nil if
+// none is.
+func firstUniqueSubset(items []value.Value, candidates []string, size int) []string {
+	var try func(start int, chosen []string) []string
+	try = func(start int, chosen []string) []string {
+		if len(chosen) == size {
+			if subsetIsUnique(items, chosen) {
+				return append([]string{}, chosen...)
+			}
+			return nil
 		}
-		// only accept primitive/scalar types as keys.
-		if f.Value.Null || f.Value.MapValue != nil || f.Value.ListValue != nil {
-			continue
+		for i := start; i < len(candidates); i++ {
+			if found := try(i+1, append(chosen, candidates[i])); found != nil {
+				return found
+			}
 		}
-		keys = append(keys, *f)
+		return nil
+	}
+	return try(0, nil)
+}
+
+func subsetIsUnique(items []value.Value, fields []string) bool {
+	seen := map[string]struct{}{}
+	for _, item := range items {
+		key := ""
+		for _, name := range fields {
+			f, _ := item.Map.Get(name)
+			key += f.Value.HumanReadable() + "\x00"
+		}
+		if _, found := seen[key]; found {
+			return false
+		}
+		seen[key] = struct{}{}
+	}
+	return true
+}
+
+// objectWalker descends through a value.Value with no schema to guide it,
+// recording every leaf it finds into a fieldpath.Set.
+type objectWalker struct {
+	path    fieldpath.Path
+	value   value.Value
+	set     *fieldpath.Set
+	guesser ListGuesser
+}
+
+// SetFromValueOption configures SetFromValue.
+type SetFromValueOption func(*objectWalker)
+
+// WithListGuesser makes SetFromValue use g, instead of DefaultListGuesser,
+// to decide list/set structure.
+func WithListGuesser(g ListGuesser) SetFromValueOption {
+	return func(w *objectWalker) { w.guesser = g }
+}
+
+// SetFromValue creates a set containing every leaf field mentioned in v.
+func SetFromValue(v value.Value, opts ...SetFromValueOption) *fieldpath.Set {
+	w := &objectWalker{
+		value:   v,
+		set:     &fieldpath.Set{},
+		guesser: DefaultListGuesser{},
 	}
-	if len(keys) > 0 {
-		return PathElement{Key: keys}
+	for _, opt := range opts {
+		opt(w)
 	}
-	return PathElement{Index: &index}
+	w.walk()
+	return w.set
+}
+
+func (w *objectWalker) walk() {
+	switch {
+	case w.value.Null:
+		w.set.Insert(w.path)
+	case w.value.List != nil:
+		items := w.value.List.Items
+		_, keys := w.guesser.GuessListRelationship(items)
+		for i, child := range items {
+			w2 := *w
+			w2.path = append(append(fieldpath.Path{}, w.path...), w.guesser.GuessItemPathElement(i, child, keys))
+			w2.value = child
+			w2.walk()
+		}
+	case w.value.Map != nil:
+		for i := range w.value.Map.Items {
+			child := w.value.Map.Items[i]
+			w2 := *w
+			w2.path = append(append(fieldpath.Path{}, w.path...), fieldpath.PathElement{FieldName: &child.Name})
+			w2.value = child.Value
+			w2.walk()
+		}
+	default:
+		// Scalar leaf.
+		w.set.Insert(w.path)
+	}
+}
+
+// GuessBestListPathElement guesses whether item is an associative list
+// element, which should be referenced by key(s), or if it is not and
+// therefore referencing by index is acceptable. It is kept for existing
+// callers; new code that wants more control should use a ListGuesser
+// directly (e.g. via WithListGuesser).
+func GuessBestListPathElement(index int, item value.Value) fieldpath.PathElement {
+	return DefaultListGuesser{}.GuessItemPathElement(index, item, nil)
 }
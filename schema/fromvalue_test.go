@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+func mapItem(fields ...value.Field) value.Value {
+	return value.Value{Map: &value.Map{Items: fields}}
+}
+
+func field(name string, v value.Value) value.Field {
+	return value.Field{Name: name, Value: v}
+}
+
+func TestCommonScalarFields(t *testing.T) {
+	items := []value.Value{
+		mapItem(field("a", value.IntValue(1)), field("b", value.StringValue("x"))),
+		mapItem(field("a", value.IntValue(2)), field("b", value.StringValue("y")), field("c", value.IntValue(3))),
+	}
+	got := commonScalarFields(items)
+	want := map[string]bool{"a": true, "b": true}
+	if len(got) != len(want) {
+		t.Fatalf("commonScalarFields = %v, want keys %v", got, want)
+	}
+	for _, name := range got {
+		if !want[name] {
+			t.Errorf("commonScalarFields returned unexpected field %v", name)
+		}
+	}
+}
+
+func TestCommonScalarFieldsNonMapItems(t *testing.T) {
+	items := []value.Value{value.IntValue(1), value.IntValue(2)}
+	if got := commonScalarFields(items); got != nil {
+		t.Fatalf("commonScalarFields(non-map items) = %v, want nil", got)
+	}
+}
+
+func TestCompositeKeyGuesserFindsKey(t *testing.T) {
+	items := []value.Value{
+		mapItem(field("name", value.StringValue("a")), field("value", value.IntValue(1))),
+		mapItem(field("name", value.StringValue("b")), field("value", value.IntValue(1))),
+	}
+	g := CompositeKeyGuesser{}
+	rel, keys := g.GuessListRelationship(items)
+	if rel != Associative || len(keys) != 1 || keys[0] != "name" {
+		t.Fatalf("GuessListRelationship = %v, %v; want Associative, [name]", rel, keys)
+	}
+}
+
+func TestCompositeKeyGuesserFallsBackWithoutUniqueSubset(t *testing.T) {
+	items := []value.Value{
+		mapItem(field("value", value.IntValue(1))),
+		mapItem(field("value", value.IntValue(1))),
+	}
+	g := CompositeKeyGuesser{}
+	rel, keys := g.GuessListRelationship(items)
+	if rel != Atomic || keys != nil {
+		t.Fatalf("GuessListRelationship = %v, %v; want Atomic, nil", rel, keys)
+	}
+}
+
+func TestCompositeKeyGuesserPrimitiveSetDetectionOptIn(t *testing.T) {
+	items := []value.Value{value.StringValue("a"), value.StringValue("b"), value.StringValue("c")}
+
+	off := CompositeKeyGuesser{}
+	if rel, keys := off.GuessListRelationship(items); rel != Atomic || keys != nil {
+		t.Fatalf("DetectPrimitiveSets off: GuessListRelationship = %v, %v; want Atomic, nil", rel, keys)
+	}
+
+	on := CompositeKeyGuesser{DetectPrimitiveSets: true}
+	rel, keys := on.GuessListRelationship(items)
+	if rel != Associative || keys == nil || len(keys) != 0 {
+		t.Fatalf("DetectPrimitiveSets on: GuessListRelationship = %v, %v; want Associative, []", rel, keys)
+	}
+	pe := on.GuessItemPathElement(0, items[0], keys)
+	if pe.Value == nil {
+		t.Fatalf("GuessItemPathElement = %+v, want a Value-addressed PathElement", pe)
+	}
+}
+
+func TestCompositeKeyGuesserPrimitiveSetDetectionRespectsDuplicates(t *testing.T) {
+	items := []value.Value{value.StringValue("a"), value.StringValue("a")}
+	g := CompositeKeyGuesser{DetectPrimitiveSets: true}
+	if rel, keys := g.GuessListRelationship(items); rel != Atomic || keys != nil {
+		t.Fatalf("GuessListRelationship with duplicates = %v, %v; want Atomic, nil", rel, keys)
+	}
+}
+
+func TestDefaultListGuesserCustomCandidateNames(t *testing.T) {
+	items := mapItem(field("uid", value.StringValue("abc")))
+	g := DefaultListGuesser{CandidateFieldNames: []string{"uid"}}
+	pe := g.GuessItemPathElement(0, items, nil)
+	if pe.Key == nil {
+		t.Fatalf("GuessItemPathElement = %+v, want a Key-addressed PathElement using the custom candidate", pe)
+	}
+
+	// The package default candidates don't include "uid", so a guesser
+	// without an override falls back to addressing by index.
+	def := DefaultListGuesser{}
+	pe = def.GuessItemPathElement(0, items, nil)
+	if pe.Key != nil {
+		t.Fatalf("GuessItemPathElement with default candidates = %+v, want index-addressed", pe)
+	}
+}
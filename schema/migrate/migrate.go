@@ -0,0 +1,365 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migrate rewrites a value.Value tree and the fieldpath.Set that
+// records its field ownership so that data authored against one version of
+// a schema.Schema remains valid, and keeps its ownership history, after the
+// schema is upgraded to a newer version. This is the kind of structural
+// rewrite a CRD author needs when bumping a CRD's served version: field
+// renames, scalar<->list type changes, atomic<->associative relationship
+// flips, and associative lists growing a key all change how a PathElement
+// for the same logical field must be constructed.
+//
+// Every entry in a MigrationPlan names the field it applies to by its full
+// Path from the root (a sequence of struct field names), not just a bare
+// name: a bare name would also match same-named fields nested elsewhere in
+// the tree that have nothing to do with the field actually being migrated.
+package migrate
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/structured-merge-diff/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/schema"
+	"sigs.k8s.io/structured-merge-diff/typed"
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+// FieldRename renames the struct field at Path (its last element) to To.
+type FieldRename struct {
+	Path []string
+	To   string
+}
+
+// FieldDeletion removes the struct field at Path.
+type FieldDeletion struct {
+	Path []string
+}
+
+// ListKeyAddition models a list at Path that did not previously have keys
+// (items referenced by index, or an atomic list) gaining Keys. Defaults
+// supplies a value for each new key field, used to synthesize a key for
+// list entries that were authored before the key field existed.
+type ListKeyAddition struct {
+	Path     []string
+	Keys     []string
+	Defaults map[string]value.Value
+}
+
+// TypeChange models the field at Path changing shape between a scalar and
+// a single-element list (the reverse of flattening a list that's known, in
+// the target schema, to always have exactly one item).
+type TypeChange struct {
+	Path []string
+	// ToList selects the direction: true to wrap an existing scalar in a
+	// one-item list, false to unwrap a one-item list back to a scalar.
+	ToList bool
+}
+
+// RelationshipChange models the list at Path flipping between Atomic and
+// Associative. On its own this has no effect on a value.Value (ownership
+// of an Atomic list is already whole-list, and an Associative list's items
+// are addressed by whatever ListKeyAddition or the existing item shape
+// already implies). Apply does not act on it at all: neither the value.Value
+// nor the ownership Set is changed by a RelationshipChange, which only
+// records the change in the MigrationPlan. List it next to a ListKeyAddition on the same Path
+// when keys are gained or lost at the same time.
+type RelationshipChange struct {
+	Path []string
+	To   schema.ElementRelationship
+}
+
+// MigrationPlan declaratively describes the structural differences between
+// two versions of a schema: the minimum a caller must say in order for
+// Apply to carry existing data and field ownership forward. It says
+// nothing about *why* the schema changed.
+type MigrationPlan struct {
+	Renames             []FieldRename
+	Deletions           []FieldDeletion
+	KeyAdditions        []ListKeyAddition
+	TypeChanges         []TypeChange
+	RelationshipChanges []RelationshipChange
+}
+
+// Migrator rewrites values and field-ownership sets produced against
+// Source/SourceType so that they are valid against Target/TargetType,
+// according to Plan.
+type Migrator struct {
+	Source        *schema.Schema
+	SourceVersion int
+	SourceType    schema.TypeRef
+
+	Target        *schema.Schema
+	TargetVersion int
+	TargetType    schema.TypeRef
+
+	Plan MigrationPlan
+}
+
+// Apply rewrites v and s in place so that they match Target instead of
+// Source. v and/or s may be nil if the caller only wants to migrate one of
+// the two (e.g. a value with no recorded ownership yet). KeyAdditions and
+// TypeChanges are only applied when v is non-nil; with v nil, s is rewritten
+// for Renames and Deletions only.
+func (m *Migrator) Apply(v *value.Value, s *fieldpath.Set) error {
+	for _, r := range m.Plan.Renames {
+		if len(r.Path) == 0 {
+			return fmt.Errorf("rename: empty path")
+		}
+		if v != nil {
+			renameField(v, r.Path, r.To)
+		}
+		if s != nil {
+			renamePaths(s, r.Path, r.To)
+		}
+	}
+
+	for _, d := range m.Plan.Deletions {
+		if len(d.Path) == 0 {
+			return fmt.Errorf("deletion: empty path")
+		}
+		if v != nil {
+			m.deleteField(v, d.Path)
+		}
+		if s != nil {
+			deletePaths(s, d.Path)
+		}
+	}
+
+	for _, k := range m.Plan.KeyAdditions {
+		if v == nil {
+			continue
+		}
+		if err := addListKeys(v, k); err != nil {
+			return fmt.Errorf("adding keys to %v: %v", k.Path, err)
+		}
+		if s != nil {
+			if list, ok := atPath(v, k.Path); ok {
+				addListKeysToSet(list, s, k)
+			}
+		}
+	}
+
+	for _, tc := range m.Plan.TypeChanges {
+		if v == nil {
+			continue
+		}
+		if err := changeType(v, tc); err != nil {
+			return fmt.Errorf("changing type of %v: %v", tc.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// atPath returns a pointer to the value at the given sequence of struct
+// field names under root, navigating only through maps (so a Path can't
+// reach into list items - a deliberate scope limit, since "rewrite this
+// struct field" is the operation every MigrationPlan entry describes).
+// It returns false if any segment of path doesn't exist.
+func atPath(root *value.Value, path []string) (*value.Value, bool) {
+	cur := root
+	for _, name := range path {
+		if cur.Map == nil {
+			return nil, false
+		}
+		f, ok := cur.Map.Get(name)
+		if !ok {
+			return nil, false
+		}
+		cur = &f.Value
+	}
+	return cur, true
+}
+
+// renameField renames the field named by path's last element, scoped to
+// exactly that path - not any other field in the tree that happens to
+// share the name.
+func renameField(v *value.Value, path []string, to string) {
+	parent, ok := atPath(v, path[:len(path)-1])
+	if !ok || parent.Map == nil {
+		return
+	}
+	name := path[len(path)-1]
+	f, ok := parent.Map.Get(name)
+	if !ok {
+		return
+	}
+	parent.Map.Set(to, f.Value)
+	parent.Map.Delete(name)
+}
+
+// deleteField removes the field at path by building a one-path ownership
+// Set naming it and reusing typed.RemoveItemsWithSchema/removingWalker to
+// strip it out - the same machinery used to drop any other field a
+// manager no longer owns, so list/map element addressing stays correct
+// even below the deleted field.
+func (m *Migrator) deleteField(v *value.Value, path []string) {
+	toRemove := &fieldpath.Set{}
+	toRemove.Insert(pathOf(path))
+	typed.RemoveItemsWithSchema(v, toRemove, m.Source, m.SourceType)
+}
+
+// pathOf builds a fieldpath.Path of plain field-name PathElements from a
+// sequence of struct field names.
+func pathOf(names []string) fieldpath.Path {
+	p := make(fieldpath.Path, 0, len(names))
+	for i := range names {
+		name := names[i]
+		p = append(p, fieldpath.PathElement{FieldName: &name})
+	}
+	return p
+}
+
+// hasPathPrefix reports whether p starts with the field-name sequence
+// prefix.
+func hasPathPrefix(p fieldpath.Path, prefix []string) bool {
+	if len(p) < len(prefix) {
+		return false
+	}
+	for i, name := range prefix {
+		if p[i].FieldName == nil || *p[i].FieldName != name {
+			return false
+		}
+	}
+	return true
+}
+
+// renamePaths rewrites every entry of s whose path starts with path to use
+// to for path's final element, leaving everything else (including
+// same-named fields elsewhere in the tree) untouched.
+func renamePaths(s *fieldpath.Set, path []string, to string) {
+	rewritten := make([]fieldpath.Path, 0, s.Len())
+	s.Iterate(func(p fieldpath.Path) {
+		if hasPathPrefix(p, path) {
+			q := append(fieldpath.Path{}, p...)
+			renamed := to
+			q[len(path)-1].FieldName = &renamed
+			p = q
+		}
+		rewritten = append(rewritten, p)
+	})
+	s.Reset(rewritten)
+}
+
+// deletePaths drops every entry of s whose path starts with path.
+func deletePaths(s *fieldpath.Set, path []string) {
+	kept := make([]fieldpath.Path, 0, s.Len())
+	s.Iterate(func(p fieldpath.Path) {
+		if !hasPathPrefix(p, path) {
+			kept = append(kept, p)
+		}
+	})
+	s.Reset(kept)
+}
+
+// addListKeys fills in k.Keys on every element of the list at k.Path that's
+// missing one, from k.Defaults, so a PathElement can be constructed for it
+// under the new, keyed schema.
+func addListKeys(v *value.Value, k ListKeyAddition) error {
+	list, ok := atPath(v, k.Path)
+	if !ok || list.List == nil {
+		return nil
+	}
+	for i := range list.List.Items {
+		item := &list.List.Items[i]
+		if item.Map == nil {
+			return fmt.Errorf("element %v is not a map, cannot synthesize keys %v", i, k.Keys)
+		}
+		for _, key := range k.Keys {
+			if _, ok := item.Map.Get(key); ok {
+				continue
+			}
+			def, ok := k.Defaults[key]
+			if !ok {
+				return fmt.Errorf("element %v has no value for new key %q and no default was given", i, key)
+			}
+			item.Map.Set(key, def)
+		}
+	}
+	return nil
+}
+
+// addListKeysToSet rewrites every entry of s addressed by index under
+// k.Path to the Key-addressed PathElement implied by the keys addListKeys
+// just filled into list: ownership recorded against the pre-migration,
+// unkeyed list was addressed by position, and that addressing goes stale
+// the moment the list becomes keyed, so it has to move to the same
+// Key-addressed PathElement a keyed validation walk would now produce for
+// that item.
+func addListKeysToSet(list *value.Value, s *fieldpath.Set, k ListKeyAddition) {
+	if list.List == nil {
+		return
+	}
+	rewritten := make([]fieldpath.Path, 0, s.Len())
+	s.Iterate(func(p fieldpath.Path) {
+		if hasPathPrefix(p, k.Path) && len(p) > len(k.Path) && p[len(k.Path)].Index != nil {
+			index := *p[len(k.Path)].Index
+			if index >= 0 && index < len(list.List.Items) {
+				if pe, ok := keyPathElement(list.List.Items[index], k.Keys); ok {
+					q := append(fieldpath.Path{}, p...)
+					q[len(k.Path)] = pe
+					p = q
+				}
+			}
+		}
+		rewritten = append(rewritten, p)
+	})
+	s.Reset(rewritten)
+}
+
+// keyPathElement builds the Key-addressed PathElement for item from keys,
+// or reports false if item is missing one of them (shouldn't happen right
+// after addListKeys has filled them all in).
+func keyPathElement(item value.Value, keys []string) (fieldpath.PathElement, bool) {
+	if item.Map == nil {
+		return fieldpath.PathElement{}, false
+	}
+	fields := make([]value.Field, 0, len(keys))
+	for _, name := range keys {
+		f, ok := item.Map.Get(name)
+		if !ok {
+			return fieldpath.PathElement{}, false
+		}
+		fields = append(fields, *f)
+	}
+	return fieldpath.PathElement{Key: fields}, true
+}
+
+// changeType wraps or unwraps the value at tc.Path between a scalar and a
+// single-element list, per tc.ToList.
+func changeType(v *value.Value, tc TypeChange) error {
+	target, ok := atPath(v, tc.Path)
+	if !ok {
+		return nil
+	}
+	if tc.ToList {
+		if target.List != nil {
+			return nil
+		}
+		item := *target
+		*target = value.Value{List: &value.List{Items: []value.Value{item}}}
+		return nil
+	}
+	if target.List == nil {
+		return nil
+	}
+	if len(target.List.Items) != 1 {
+		return fmt.Errorf("list has %v items, want exactly 1 to collapse to a scalar", len(target.List.Items))
+	}
+	*target = target.List.Items[0]
+	return nil
+}
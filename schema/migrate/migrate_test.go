@@ -0,0 +1,284 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+func mapOf(fields ...value.Field) value.Value {
+	return value.Value{Map: &value.Map{Items: fields}}
+}
+
+func field(name string, v value.Value) value.Field {
+	return value.Field{Name: name, Value: v}
+}
+
+// indexedPath builds the fieldpath.Path for the item at index under the
+// field-name sequence fieldNames, the way an unkeyed list is addressed.
+func indexedPath(fieldNames []string, index int) fieldpath.Path {
+	idx := index
+	return append(pathOf(fieldNames), fieldpath.PathElement{Index: &idx})
+}
+
+// keyedPath builds the fieldpath.Path for the item keyed by keys under the
+// field-name sequence fieldNames, the way a keyed list is addressed.
+func keyedPath(fieldNames []string, keys ...value.Field) fieldpath.Path {
+	return append(pathOf(fieldNames), fieldpath.PathElement{Key: keys})
+}
+
+// TestRenameFieldIsPathScoped is the round-trip the review asked for:
+// renaming spec.name must not touch an unrelated field that happens to
+// share the name "name" nested elsewhere in the tree.
+func TestRenameFieldIsPathScoped(t *testing.T) {
+	v := mapOf(
+		field("spec", mapOf(field("name", value.StringValue("a")))),
+		field("status", mapOf(field("name", value.StringValue("b")))),
+	)
+
+	renameField(&v, []string{"spec", "name"}, "displayName")
+
+	spec, _ := v.Map.Get("spec")
+	if _, ok := spec.Value.Map.Get("name"); ok {
+		t.Fatalf("spec.name still present after rename")
+	}
+	renamed, ok := spec.Value.Map.Get("displayName")
+	if !ok || renamed.Value.String == nil || *renamed.Value.String != "a" {
+		t.Fatalf("spec.displayName = %+v, want StringValue(a)", renamed)
+	}
+
+	status, _ := v.Map.Get("status")
+	if _, ok := status.Value.Map.Get("name"); !ok {
+		t.Fatalf("status.name was renamed too; rename must be scoped to its own path")
+	}
+}
+
+func TestAtPath(t *testing.T) {
+	// Only atPath is exercised here; renamePaths/deletePaths/addListKeysToSet
+	// (the fieldpath.Set side of a migration) have their own tests below.
+	// The value.Value side of a deletion goes through
+	// typed.RemoveItemsWithSchema, which needs a real schema.Schema/TypeRef
+	// to resolve and so isn't exercised by this package's tests.
+	v := mapOf(
+		field("spec", mapOf(field("replicas", value.IntValue(1)))),
+	)
+	spec, ok := atPath(&v, []string{"spec"})
+	if !ok || spec.Map == nil {
+		t.Fatalf("atPath(spec) = %v, %v", spec, ok)
+	}
+	if _, ok := atPath(&v, []string{"spec", "replicas"}); !ok {
+		t.Fatalf("atPath(spec.replicas) not found")
+	}
+	if _, ok := atPath(&v, []string{"spec", "missing"}); ok {
+		t.Fatalf("atPath(spec.missing) unexpectedly found")
+	}
+}
+
+func TestChangeTypeWrapAndCollapse(t *testing.T) {
+	v := mapOf(field("spec", mapOf(field("image", value.StringValue("nginx")))))
+
+	if err := changeType(&v, TypeChange{Path: []string{"spec", "image"}, ToList: true}); err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	spec, _ := v.Map.Get("spec")
+	image, _ := spec.Value.Map.Get("image")
+	if image.Value.List == nil || len(image.Value.List.Items) != 1 || *image.Value.List.Items[0].String != "nginx" {
+		t.Fatalf("after wrap, spec.image = %+v, want a one-item list containing nginx", image.Value)
+	}
+
+	if err := changeType(&v, TypeChange{Path: []string{"spec", "image"}, ToList: false}); err != nil {
+		t.Fatalf("collapse: %v", err)
+	}
+	spec, _ = v.Map.Get("spec")
+	image, _ = spec.Value.Map.Get("image")
+	if image.Value.String == nil || *image.Value.String != "nginx" {
+		t.Fatalf("after collapse, spec.image = %+v, want StringValue(nginx)", image.Value)
+	}
+}
+
+func TestChangeTypeCollapseRejectsMultiItemList(t *testing.T) {
+	v := mapOf(field("spec", mapOf(field("images", value.Value{List: &value.List{
+		Items: []value.Value{value.StringValue("a"), value.StringValue("b")},
+	}}))))
+
+	err := changeType(&v, TypeChange{Path: []string{"spec", "images"}, ToList: false})
+	if err == nil {
+		t.Fatalf("collapsing a two-item list should have failed")
+	}
+}
+
+func TestAddListKeysFillsDefaults(t *testing.T) {
+	v := mapOf(field("spec", mapOf(field("containers", value.Value{List: &value.List{
+		Items: []value.Value{
+			mapOf(field("image", value.StringValue("nginx"))),
+		},
+	}}))))
+
+	err := addListKeys(&v, ListKeyAddition{
+		Path:     []string{"spec", "containers"},
+		Keys:     []string{"name"},
+		Defaults: map[string]value.Value{"name": value.StringValue("main")},
+	})
+	if err != nil {
+		t.Fatalf("addListKeys: %v", err)
+	}
+
+	spec, _ := v.Map.Get("spec")
+	containers, _ := spec.Value.Map.Get("containers")
+	item := containers.Value.List.Items[0]
+	name, ok := item.Map.Get("name")
+	if !ok || name.Value.String == nil || *name.Value.String != "main" {
+		t.Fatalf("container.name = %+v, want StringValue(main)", name)
+	}
+}
+
+func TestAddListKeysErrorsWithoutDefault(t *testing.T) {
+	v := mapOf(field("spec", mapOf(field("containers", value.Value{List: &value.List{
+		Items: []value.Value{
+			mapOf(field("image", value.StringValue("nginx"))),
+		},
+	}}))))
+
+	err := addListKeys(&v, ListKeyAddition{
+		Path: []string{"spec", "containers"},
+		Keys: []string{"name"},
+	})
+	if err == nil {
+		t.Fatalf("addListKeys should have failed without a default for the new key")
+	}
+}
+
+func TestRenamePathsIsScoped(t *testing.T) {
+	s := &fieldpath.Set{}
+	s.Insert(pathOf([]string{"spec", "name"}))
+	s.Insert(pathOf([]string{"status", "name"}))
+
+	renamePaths(s, []string{"spec", "name"}, "displayName")
+
+	if s.Has(pathOf([]string{"spec", "name"})) {
+		t.Fatalf("spec.name should have been renamed away")
+	}
+	if !s.Has(pathOf([]string{"spec", "displayName"})) {
+		t.Fatalf("spec.displayName should be present after rename")
+	}
+	if !s.Has(pathOf([]string{"status", "name"})) {
+		t.Fatalf("status.name must be untouched; rename is scoped to its own path")
+	}
+}
+
+func TestDeletePathsIsScoped(t *testing.T) {
+	s := &fieldpath.Set{}
+	s.Insert(pathOf([]string{"spec", "name"}))
+	s.Insert(pathOf([]string{"status", "name"}))
+
+	deletePaths(s, []string{"spec", "name"})
+
+	if s.Has(pathOf([]string{"spec", "name"})) {
+		t.Fatalf("spec.name should have been deleted from the set")
+	}
+	if !s.Has(pathOf([]string{"status", "name"})) {
+		t.Fatalf("status.name must be untouched; deletion is scoped to its own path")
+	}
+}
+
+func TestAddListKeysToSetRewritesIndexToKey(t *testing.T) {
+	list := value.Value{List: &value.List{Items: []value.Value{
+		mapOf(field("name", value.StringValue("main")), field("image", value.StringValue("nginx"))),
+	}}}
+
+	s := &fieldpath.Set{}
+	s.Insert(indexedPath([]string{"spec", "containers"}, 0))
+
+	k := ListKeyAddition{Path: []string{"spec", "containers"}, Keys: []string{"name"}}
+	addListKeysToSet(&list, s, k)
+
+	if s.Has(indexedPath([]string{"spec", "containers"}, 0)) {
+		t.Fatalf("index-addressed entry should have been rewritten")
+	}
+	want := keyedPath([]string{"spec", "containers"}, field("name", value.StringValue("main")))
+	if !s.Has(want) {
+		t.Fatalf("set should now address the container by its synthesized key")
+	}
+}
+
+// TestApplyRoundTripRenameAndKeyAddition is the Migrator.Apply-level round
+// trip the review asked for: a rename and a key addition applied together
+// against both v and s. Deletions are exercised separately (the Set side in
+// TestDeletePathsIsScoped) since the value.Value side of a deletion goes through
+// typed.RemoveItemsWithSchema, which needs a real schema.Schema/TypeRef to
+// resolve and so can't be driven end-to-end in this package's tests.
+func TestApplyRoundTripRenameAndKeyAddition(t *testing.T) {
+	v := mapOf(field("spec", mapOf(
+		field("name", value.StringValue("a")),
+		field("containers", value.Value{List: &value.List{Items: []value.Value{
+			mapOf(field("image", value.StringValue("nginx"))),
+		}}}),
+	)))
+
+	s := &fieldpath.Set{}
+	s.Insert(pathOf([]string{"spec", "name"}))
+	s.Insert(indexedPath([]string{"spec", "containers"}, 0))
+
+	m := &Migrator{
+		Plan: MigrationPlan{
+			Renames: []FieldRename{
+				{Path: []string{"spec", "name"}, To: "displayName"},
+			},
+			KeyAdditions: []ListKeyAddition{
+				{
+					Path:     []string{"spec", "containers"},
+					Keys:     []string{"name"},
+					Defaults: map[string]value.Value{"name": value.StringValue("main")},
+				},
+			},
+		},
+	}
+
+	if err := m.Apply(&v, s); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	spec, _ := v.Map.Get("spec")
+	if _, ok := spec.Value.Map.Get("name"); ok {
+		t.Fatalf("spec.name should have been renamed away in v")
+	}
+	if _, ok := spec.Value.Map.Get("displayName"); !ok {
+		t.Fatalf("spec.displayName missing from v after rename")
+	}
+	containers, _ := spec.Value.Map.Get("containers")
+	item := containers.Value.List.Items[0]
+	name, ok := item.Map.Get("name")
+	if !ok || name.Value.String == nil || *name.Value.String != "main" {
+		t.Fatalf("container key not synthesized in v: %+v", item)
+	}
+
+	if s.Has(pathOf([]string{"spec", "name"})) {
+		t.Fatalf("s should no longer own spec.name")
+	}
+	if !s.Has(pathOf([]string{"spec", "displayName"})) {
+		t.Fatalf("s should now own spec.displayName")
+	}
+	if s.Has(indexedPath([]string{"spec", "containers"}, 0)) {
+		t.Fatalf("s should no longer address the container by index")
+	}
+	if !s.Has(keyedPath([]string{"spec", "containers"}, field("name", value.StringValue("main")))) {
+		t.Fatalf("s should now address the container by its synthesized key")
+	}
+}
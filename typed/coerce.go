@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"strconv"
+
+	"sigs.k8s.io/structured-merge-diff/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/schema"
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+// Coercer is given a chance to rewrite a scalar value that doesn't match
+// what the schema expects at path, before validation gives up and rejects
+// it. This exists because real-world YAML/JSON routinely disagrees with a
+// schema in lossless ways: an integer-valued float, a numeric string
+// produced by env-var substitution, and so on.
+//
+// CoerceScalar is only ever called once doScalar has already determined
+// that got doesn't satisfy want, so it never needs to handle a got that
+// already matches want.
+type Coercer interface {
+	// CoerceScalar is called with the scalar kind the schema wants and the
+	// value that was actually found at path. If it can losslessly
+	// reinterpret got as want, it returns the rewritten value and true;
+	// the walker then rewrites the tree so later merges see the canonical
+	// value, and records the rewrite as a Coerced ValidationError (see
+	// ValidationErrors.Errors) rather than a rejection. Otherwise it
+	// returns false, and the original type-mismatch error is reported.
+	CoerceScalar(path fieldpath.Path, want schema.Scalar, got value.Value) (value.Value, bool)
+}
+
+// DefaultCoercer implements the lossless coercions that are safe to apply
+// without any opt-in: a numeric string (as might arrive via env-var
+// substitution in a YAML manifest) can always stand in for a Numeric, since
+// parsing it is lossless. Boolean string coercion is separate
+// (CoerceBoolStrings) because "1"/"0" are common, legitimate string values,
+// so silently reinterpreting them as booleans is only appropriate when a
+// caller has said they want that.
+type DefaultCoercer struct {
+	// CoerceBoolStrings opts into treating the strings "true", "false",
+	// "1" and "0" as booleans.
+	CoerceBoolStrings bool
+}
+
+// CoerceScalar implements Coercer.
+func (c DefaultCoercer) CoerceScalar(path fieldpath.Path, want schema.Scalar, got value.Value) (value.Value, bool) {
+	switch want {
+	case schema.Numeric:
+		if got.String == nil {
+			break
+		}
+		s := string(*got.String)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return value.IntValue(int(i)), true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return value.FloatValue(f), true
+		}
+	case schema.Boolean:
+		if c.CoerceBoolStrings && got.String != nil {
+			if b, ok := parseBool(string(*got.String)); ok {
+				return value.BooleanValue(b), true
+			}
+		}
+	}
+	return value.Value{}, false
+}
+
+// parseBool is stricter than strconv.ParseBool: we only want to treat the
+// exact literals callers asked for as booleans, not e.g. "t"/"T"/"TRUE".
+func parseBool(s string) (bool, bool) {
+	switch s {
+	case "true", "1":
+		return true, true
+	case "false", "0":
+		return false, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"testing"
+
+	"sigs.k8s.io/structured-merge-diff/schema"
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+func TestDefaultCoercerNumericString(t *testing.T) {
+	c := DefaultCoercer{}
+
+	got, ok := c.CoerceScalar(nil, schema.Numeric, value.StringValue("42"))
+	if !ok || got.Int == nil || *got.Int != 42 {
+		t.Fatalf("CoerceScalar(Numeric, %q) = %+v, %v; want IntValue(42), true", "42", got, ok)
+	}
+
+	got, ok = c.CoerceScalar(nil, schema.Numeric, value.StringValue("4.5"))
+	if !ok || got.Float == nil || *got.Float != 4.5 {
+		t.Fatalf("CoerceScalar(Numeric, %q) = %+v, %v; want FloatValue(4.5), true", "4.5", got, ok)
+	}
+
+	if _, ok := c.CoerceScalar(nil, schema.Numeric, value.StringValue("not a number")); ok {
+		t.Fatalf("CoerceScalar(Numeric, non-numeric string) should fail")
+	}
+}
+
+func TestDefaultCoercerBoolStringOptIn(t *testing.T) {
+	off := DefaultCoercer{}
+	if _, ok := off.CoerceScalar(nil, schema.Boolean, value.StringValue("true")); ok {
+		t.Fatalf("bool-string coercion must stay off without CoerceBoolStrings")
+	}
+
+	on := DefaultCoercer{CoerceBoolStrings: true}
+	got, ok := on.CoerceScalar(nil, schema.Boolean, value.StringValue("true"))
+	if !ok || got.Boolean == nil || !bool(*got.Boolean) {
+		t.Fatalf("CoerceScalar(Boolean, %q) = %+v, %v; want BooleanValue(true), true", "true", got, ok)
+	}
+
+	if _, ok := on.CoerceScalar(nil, schema.Boolean, value.StringValue("yes")); ok {
+		t.Fatalf("CoerceScalar(Boolean, %q) should not be recognized", "yes")
+	}
+}
+
+// TestValidationCoercesNumericStringInPlace drives a numeric-string value
+// through doScalar/coerceOrError - the reachable entry point in this tree,
+// since schema.Schema has no in-tree Resolve implementation to exercise the
+// full AsTyped path against. It's the same walk AsTyped's Validate uses
+// once a real Schema is available to dispatch into doScalar.
+func TestValidationCoercesNumericStringInPlace(t *testing.T) {
+	got := value.StringValue("42")
+	v := validation{
+		coercer:  DefaultCoercer{},
+		valuePtr: &got,
+	}
+
+	errs := v.doScalar(schema.Numeric, got)
+	if errs.HasErrors() {
+		t.Fatalf("a successful coercion must not count as HasErrors: %v", errs)
+	}
+	if len(errs) != 1 || !errs[0].Coerced {
+		t.Fatalf("doScalar errs = %+v, want a single Coerced entry", errs)
+	}
+	if got.Int == nil || *got.Int != 42 {
+		t.Fatalf("valuePtr not rewritten in place: %+v", got)
+	}
+}
+
+func TestValidationRejectsUncoercibleScalar(t *testing.T) {
+	got := value.StringValue("not a number")
+	v := validation{
+		coercer:  DefaultCoercer{},
+		valuePtr: &got,
+	}
+
+	errs := v.doScalar(schema.Numeric, got)
+	if !errs.HasErrors() {
+		t.Fatalf("an uncoercible mismatch must still be reported as an error")
+	}
+}
+
+func TestValidationWithoutCoercerStillRejects(t *testing.T) {
+	got := value.StringValue("42")
+	v := validation{valuePtr: &got}
+
+	errs := v.doScalar(schema.Numeric, got)
+	if !errs.HasErrors() {
+		t.Fatalf("with no coercer configured, a type mismatch must be rejected")
+	}
+	if got.String == nil || *got.String != "42" {
+		t.Fatalf("value must be untouched when there's no coercer: %+v", got)
+	}
+}
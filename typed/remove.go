@@ -25,6 +25,17 @@ type removingWalker struct {
 	toRemove *fieldpath.Set
 }
 
+// RemoveItemsWithSchema removes each item identified by toRemove from
+// value, guided by schema/typeRef the same way validation is: list items
+// are addressed the same way listItemToPathElement would address them, and
+// atomic lists/maps are left untouched. It's exported so that other
+// packages needing to strip specific fields out of a value (e.g.
+// schema/migrate, dropping a deleted field) don't have to reimplement this
+// walk.
+func RemoveItemsWithSchema(value *value.Value, toRemove *fieldpath.Set, schema *schema.Schema, typeRef schema.TypeRef) {
+	removeItemsWithSchema(value, toRemove, schema, typeRef)
+}
+
 func removeItemsWithSchema(value *value.Value, toRemove *fieldpath.Set, schema *schema.Schema, typeRef schema.TypeRef) {
 	w := &removingWalker{
 		value:    value,
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sort"
+
+	"sigs.k8s.io/structured-merge-diff/schema"
+)
+
+// maxSuggestions caps how many "did you mean" candidates we'll ever report;
+// beyond this the list stops being useful to a human reading the error.
+const maxSuggestions = 5
+
+// levenshteinDistance returns the edit distance between a and b (insertions,
+// deletions and substitutions all cost one). Transpositions are not given a
+// discount, i.e. this is plain Levenshtein rather than Damerau-Levenshtein,
+// which is precise enough for ranking field-name suggestions.
+func levenshteinDistance(a, b string) int {
+	if len(a) == 0 {
+		return len(b)
+	}
+	if len(b) == 0 {
+		return len(a)
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			del := prev[j] + 1
+			ins := curr[j-1] + 1
+			sub := prev[j-1] + cost
+			m := del
+			if ins < m {
+				m = ins
+			}
+			if sub < m {
+				m = sub
+			}
+			curr[j] = m
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// maxOf keeps the suggestion threshold calculation below readable.
+func maxOf(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// suggestNames returns, out of candidates, the ones close enough to name to
+// be worth suggesting, nearest first, capped at maxSuggestions. It returns
+// nil if nothing is close enough.
+func suggestNames(name string, candidates []string) []string {
+	type scored struct {
+		name     string
+		distance int
+	}
+
+	var close []scored
+	for _, c := range candidates {
+		d := levenshteinDistance(name, c)
+		threshold := maxOf(len(name)/2, maxOf(len(c)/2, 1))
+		if d < threshold {
+			close = append(close, scored{name: c, distance: d})
+		}
+	}
+	if len(close) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(close, func(i, j int) bool {
+		return close[i].distance < close[j].distance
+	})
+	if len(close) > maxSuggestions {
+		close = close[:maxSuggestions]
+	}
+
+	out := make([]string, 0, len(close))
+	for _, s := range close {
+		out = append(out, s.name)
+	}
+	return out
+}
+
+// SuggestFieldName looks up typeRef in s and, if it resolves to a struct,
+// returns the allowed field names that are close enough to name to be a
+// likely typo, nearest first. It returns nil if typeRef isn't a struct or no
+// field name is close enough.
+func SuggestFieldName(s *schema.Schema, typeRef schema.TypeRef, name string) []string {
+	a, ok := s.Resolve(typeRef)
+	if !ok || a.Struct == nil {
+		return nil
+	}
+	candidates := make([]string, 0, len(a.Struct.Fields))
+	for _, f := range a.Struct.Fields {
+		candidates = append(candidates, f.Name)
+	}
+	return suggestNames(name, candidates)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import "testing"
+
+func TestLevenshteinDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"name", "nme", 1},
+		{"color", "colour", 1},
+		{"replcias", "replicas", 2},
+	}
+	for _, c := range cases {
+		if got := levenshteinDistance(c.a, c.b); got != c.want {
+			t.Errorf("levenshteinDistance(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestSuggestNames(t *testing.T) {
+	candidates := []string{"replicas", "selector", "template", "minReadySeconds"}
+
+	got := suggestNames("replcias", candidates)
+	if len(got) == 0 || got[0] != "replicas" {
+		t.Fatalf("suggestNames(replcias) = %v, want first suggestion replicas", got)
+	}
+
+	if got := suggestNames("completelyUnrelatedFieldName", candidates); got != nil {
+		t.Fatalf("suggestNames(completelyUnrelatedFieldName) = %v, want nil", got)
+	}
+}
+
+func TestSuggestNamesCapped(t *testing.T) {
+	// All within one edit of "apple0", so every candidate clears the
+	// threshold; the result must still be capped at maxSuggestions.
+	candidates := []string{"apple1", "apple2", "apple3", "apple4", "apple5", "apple6", "apple7"}
+	got := suggestNames("apple0", candidates)
+	if len(got) != maxSuggestions {
+		t.Fatalf("suggestNames returned %v suggestions (%v), want exactly %v", len(got), got, maxSuggestions)
+	}
+}
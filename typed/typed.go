@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package typed
+
+import (
+	"sigs.k8s.io/structured-merge-diff/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/schema"
+	"sigs.k8s.io/structured-merge-diff/value"
+)
+
+// TypedValue is a value.Value paired with typeRef in schema, built by AsTyped
+// (which validates it) or AsTypeable (which does not) below.
+type TypedValue struct {
+	value   value.Value
+	schema  *schema.Schema
+	typeRef schema.TypeRef
+	coercer Coercer
+}
+
+// ValidationOption configures the validation performed by AsTyped/AsTypeable.
+type ValidationOption func(*TypedValue)
+
+// WithCoercer makes validation consult c before rejecting a scalar whose Go
+// type doesn't match the schema, instead of always reporting a type error.
+// This is the only way a caller outside this package can supply a Coercer:
+// without it, DefaultCoercer and the rest of the coerceOrError path can
+// never fire.
+func WithCoercer(c Coercer) ValidationOption {
+	return func(tv *TypedValue) { tv.coercer = c }
+}
+
+// AsTypeable wraps v as a TypedValue against typeRef in s without validating
+// it yet. Call Validate to check it.
+func AsTypeable(v value.Value, s *schema.Schema, typeRef schema.TypeRef, opts ...ValidationOption) *TypedValue {
+	tv := &TypedValue{value: v, schema: s, typeRef: typeRef}
+	for _, opt := range opts {
+		opt(tv)
+	}
+	return tv
+}
+
+// AsTyped wraps v as a TypedValue against typeRef in s and validates it
+// immediately, returning an error if it doesn't conform.
+func AsTyped(v value.Value, s *schema.Schema, typeRef schema.TypeRef, opts ...ValidationOption) (*TypedValue, error) {
+	tv := AsTypeable(v, s, typeRef, opts...)
+	if err := tv.Validate(); err != nil {
+		return nil, err
+	}
+	return tv, nil
+}
+
+// AsValue returns the value.Value underlying tv. A Coercer supplied via
+// WithCoercer may have rewritten it in place the last time Validate ran.
+func (tv *TypedValue) AsValue() *value.Value {
+	return &tv.value
+}
+
+// Validate (re)walks tv's value against its schema/typeRef. A scalar that
+// tv's Coercer successfully rewrites is recorded as a Coerced
+// ValidationError rather than a rejection, so a result that's only
+// Coercions doesn't come back as an error here - see
+// ValidationErrors.HasErrors.
+func (tv *TypedValue) Validate() error {
+	v := validation{
+		path:     fieldpath.Path{},
+		value:    tv.value,
+		valuePtr: &tv.value,
+		schema:   tv.schema,
+		typeRef:  tv.typeRef,
+		coercer:  tv.coercer,
+	}
+	errs := v.validate()
+	if errs.HasErrors() {
+		return errs.Errors()
+	}
+	return nil
+}
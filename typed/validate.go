@@ -21,23 +21,43 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/kubernetes-sigs/structured-merge-diff/fieldpath"
-	"github.com/kubernetes-sigs/structured-merge-diff/schema"
-	"github.com/kubernetes-sigs/structured-merge-diff/value"
+	"sigs.k8s.io/structured-merge-diff/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/schema"
+	"sigs.k8s.io/structured-merge-diff/value"
 )
 
 // ValidationError reports an error about a particular field
 type ValidationError struct {
 	Path         fieldpath.Path
 	ErrorMessage string
+
+	// Suggestions holds allowed names that are close enough to whatever
+	// was rejected that they're probably what the user meant. It is nil
+	// when no candidate was close enough to suggest.
+	Suggestions []string
+
+	// Coerced is true when ErrorMessage doesn't describe a rejected
+	// value, but a value that a Coercer successfully rewrote in place.
+	// Callers that want to audit lossy input can filter on this field;
+	// it's otherwise safe to treat like any other ValidationError.
+	Coerced bool
 }
 
 // Error returns a human readable error message.
 func (ve ValidationError) Error() string {
+	if len(ve.Suggestions) > 0 {
+		return fmt.Sprintf("%s: %v (did you mean %v?)", ve.Path, ve.ErrorMessage, strings.Join(ve.Suggestions, ", "))
+	}
 	return fmt.Sprintf("%s: %v", ve.Path, ve.ErrorMessage)
 }
 
 // ValidationErrors accumulates multiple validation error messages.
+//
+// A ValidationErrors returned by validate() may be non-nil while containing
+// no actual rejection: a Coercer can turn what would have been a type error
+// into a Coerced entry that's only there for audit purposes. Callers must
+// use HasErrors (or Errors) rather than a bare nil/len check to decide
+// whether validation failed.
 type ValidationErrors []ValidationError
 
 // Error returns a human readable error message reporting each error in the
@@ -53,11 +73,63 @@ func (errs ValidationErrors) Error() string {
 	return strings.Join(messages, "\n")
 }
 
+// HasErrors reports whether errs contains any entry that represents an
+// actual rejection, as opposed to an audited Coercer rewrite. A caller
+// using the common `if errs != nil { return errs }` pattern should guard it
+// with HasErrors, since a non-nil ValidationErrors may contain only Coerced
+// entries.
+func (errs ValidationErrors) HasErrors() bool {
+	for _, e := range errs {
+		if !e.Coerced {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns the subset of errs that represent actual rejections,
+// dropping entries that only record a successful Coercer rewrite. It
+// returns nil if nothing in errs was an actual rejection.
+func (errs ValidationErrors) Errors() ValidationErrors {
+	if !errs.HasErrors() {
+		return nil
+	}
+	out := make(ValidationErrors, 0, len(errs))
+	for _, e := range errs {
+		if !e.Coerced {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Coercions returns the subset of errs that record a successful Coercer
+// rewrite, for callers that want to audit lossy input.
+func (errs ValidationErrors) Coercions() ValidationErrors {
+	var out ValidationErrors
+	for _, e := range errs {
+		if e.Coerced {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
 type validation struct {
 	path    fieldpath.Path
 	value   value.Value
 	schema  *schema.Schema
 	typeRef schema.TypeRef
+
+	// coercer, if non-nil, is given a chance to rewrite a scalar that
+	// would otherwise fail doScalar's type check.
+	coercer Coercer
+	// valuePtr points at the same storage as value, when that storage is
+	// addressable (i.e. we're looking at a real field of a real parent
+	// map or list, not a detached copy). It is nil otherwise. doScalar
+	// writes a coerced value through it so later merges see the
+	// canonical type.
+	valuePtr *value.Value
 }
 
 func (v validation) error(format string, args ...interface{}) ValidationError {
@@ -95,20 +167,39 @@ func (v validation) doScalar(t schema.Scalar, value value.Value) ValidationError
 	case schema.Numeric:
 		if value.Float == nil && value.Int == nil {
 			// TODO: should the schema separate int and float?
-			return ValidationErrors{v.error("expected numeric (int or float), got %v", value.HumanReadable())}
+			return v.coerceOrError(t, value, "expected numeric (int or float), got %v", value.HumanReadable())
 		}
 	case schema.String:
 		if value.String == nil {
-			return ValidationErrors{v.error("expected string, got %v", value.HumanReadable())}
+			return v.coerceOrError(t, value, "expected string, got %v", value.HumanReadable())
 		}
 	case schema.Boolean:
 		if value.Boolean == nil {
-			return ValidationErrors{v.error("expected boolean, got %v", value.HumanReadable())}
+			return v.coerceOrError(t, value, "expected boolean, got %v", value.HumanReadable())
 		}
 	}
 	return nil
 }
 
+// coerceOrError is called once doScalar has already determined that value
+// doesn't match want. If v.coercer can rewrite value into something that
+// does match, that rewrite is recorded (in-place, if v.valuePtr lets us)
+// and reported as a Coerced ValidationError rather than a rejection;
+// otherwise the original type error, built from format/args, is returned.
+func (v validation) coerceOrError(want schema.Scalar, got value.Value, format string, args ...interface{}) ValidationErrors {
+	if v.coercer != nil {
+		if coerced, ok := v.coercer.CoerceScalar(v.path, want, got); ok {
+			if v.valuePtr != nil {
+				*v.valuePtr = coerced
+			}
+			ve := v.error("coerced %v to %v", got.HumanReadable(), coerced.HumanReadable())
+			ve.Coerced = true
+			return ValidationErrors{ve}
+		}
+	}
+	return ValidationErrors{v.error(format, args...)}
+}
+
 func (v validation) doStruct(t schema.Struct, value value.Value) (errs ValidationErrors) {
 	switch {
 	case value.Null:
@@ -121,12 +212,14 @@ func (v validation) doStruct(t schema.Struct, value value.Value) (errs Validatio
 	}
 
 	allowedNames := map[string]struct{}{}
+	allowedNameList := make([]string, 0, len(t.Fields))
 	m := *value.Map
 	for i := range t.Fields {
 		// I don't want to use the loop variable since a reference
 		// might outlive the loop iteration (in an error message).
 		f := t.Fields[i]
 		allowedNames[f.Name] = struct{}{}
+		allowedNameList = append(allowedNameList, f.Name)
 		child, ok := m.Get(f.Name)
 		if !ok {
 			// All fields are optional
@@ -135,6 +228,7 @@ func (v validation) doStruct(t schema.Struct, value value.Value) (errs Validatio
 		v2 := v
 		v2.path = append(v.path, fieldpath.PathElement{FieldName: &f.Name})
 		v2.value = child.Value
+		v2.valuePtr = &child.Value
 		v2.typeRef = f.Type
 		errs = append(errs, v2.validate()...)
 	}
@@ -142,7 +236,9 @@ func (v validation) doStruct(t schema.Struct, value value.Value) (errs Validatio
 	// All fields may be optional, but unknown fields are not allowed.
 	for _, f := range m.Items {
 		if _, allowed := allowedNames[f.Name]; !allowed {
-			errs = append(errs, v.error("field %v is not mentioned in the schema", f.Name))
+			ve := v.error("field %v is not mentioned in the schema", f.Name)
+			ve.Suggestions = suggestNames(f.Name, allowedNameList)
+			errs = append(errs, ve)
 		}
 	}
 
@@ -223,10 +319,11 @@ func (v validation) doList(t schema.List, value value.Value) (errs ValidationErr
 		return ValidationErrors{v.error("expected list")}
 	}
 
-	observedKeys := map[string]struct{}{}
+	var observedKeys fieldpath.PathElementMap
 
 	list := *value.List
-	for i, child := range list.Items {
+	for i := range list.Items {
+		child := list.Items[i]
 		pe, err := listItemToPathElement(t, i, child)
 		if err != nil {
 			errs = append(errs, v.error("element %v: %v", i, err.Error()))
@@ -235,14 +332,14 @@ func (v validation) doList(t schema.List, value value.Value) (errs ValidationErr
 			// this element.
 			continue
 		}
-		keyStr := pe.String()
-		if _, found := observedKeys[keyStr]; found {
-			errs = append(errs, v.error("duplicate entries for key %v", keyStr))
+		if _, found := observedKeys.Get(pe); found {
+			errs = append(errs, v.error("duplicate entries for key %v", pe.String()))
 		}
-		observedKeys[keyStr] = struct{}{}
+		observedKeys.Insert(pe, struct{}{})
 		v2 := v
 		v2.path = append(v.path, pe)
 		v2.value = child
+		v2.valuePtr = &list.Items[i]
 		v2.typeRef = t.ElementType
 		errs = append(errs, v2.validate()...)
 	}
@@ -261,11 +358,13 @@ func (v validation) doMap(t schema.Map, value value.Value) (errs ValidationError
 		return ValidationErrors{v.error("expected list, found %v", value.HumanReadable())}
 	}
 
-	for _, item := range value.Map.Items {
+	for i := range value.Map.Items {
+		item := value.Map.Items[i]
 		v2 := v
 		name := item.Name
 		v2.path = append(v.path, fieldpath.PathElement{FieldName: &name})
 		v2.value = item.Value
+		v2.valuePtr = &value.Map.Items[i].Value
 		v2.typeRef = t.ElementType
 		errs = append(errs, v2.validate()...)
 	}
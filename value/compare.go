@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "strings"
+
+// valueKind orders the mutually exclusive forms a Value can take, so that
+// values of different kinds still compare consistently with one another.
+type valueKind int
+
+const (
+	nullKind valueKind = iota
+	booleanKind
+	intKind
+	floatKind
+	stringKind
+	listKind
+	mapKind
+)
+
+func kindOf(v Value) valueKind {
+	switch {
+	case v.Null:
+		return nullKind
+	case v.Boolean != nil:
+		return booleanKind
+	case v.Int != nil:
+		return intKind
+	case v.Float != nil:
+		return floatKind
+	case v.String != nil:
+		return stringKind
+	case v.List != nil:
+		return listKind
+	case v.Map != nil:
+		return mapKind
+	default:
+		return nullKind
+	}
+}
+
+// Compare provides a total order over Value, returning -1, 0 or 1 if a is
+// less than, equal to, or greater than b. It exists so that callers (e.g.
+// fieldpath.PathElementMap) can order or deduplicate Values without relying
+// on their HumanReadable() rendering, which is not guaranteed to be
+// injective.
+func Compare(a, b Value) int {
+	ak, bk := kindOf(a), kindOf(b)
+	if ak != bk {
+		if ak < bk {
+			return -1
+		}
+		return 1
+	}
+
+	switch ak {
+	case nullKind:
+		return 0
+	case booleanKind:
+		return compareBool(bool(*a.Boolean), bool(*b.Boolean))
+	case intKind:
+		return compareInt64(int64(*a.Int), int64(*b.Int))
+	case floatKind:
+		return compareFloat64(float64(*a.Float), float64(*b.Float))
+	case stringKind:
+		return strings.Compare(string(*a.String), string(*b.String))
+	case listKind:
+		return compareLists(a.List.Items, b.List.Items)
+	case mapKind:
+		return compareMaps(a.Map.Items, b.Map.Items)
+	default:
+		return 0
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloat64(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareLists(a, b []Value) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt64(int64(len(a)), int64(len(b)))
+}
+
+func compareMaps(a, b []Field) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i].Name, b[i].Name); c != 0 {
+			return c
+		}
+		if c := Compare(a[i].Value, b[i].Value); c != 0 {
+			return c
+		}
+	}
+	return compareInt64(int64(len(a)), int64(len(b)))
+}
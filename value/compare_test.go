@@ -0,0 +1,66 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package value
+
+import "testing"
+
+func TestCompareEqual(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b Value
+	}{
+		{"null", Value{Null: true}, Value{Null: true}},
+		{"bool", BooleanValue(true), BooleanValue(true)},
+		{"int", IntValue(3), IntValue(3)},
+		{"float", FloatValue(3.5), FloatValue(3.5)},
+		{"string", StringValue("a"), StringValue("a")},
+		{"list", Value{List: &List{Items: []Value{IntValue(1), IntValue(2)}}}, Value{List: &List{Items: []Value{IntValue(1), IntValue(2)}}}},
+		{"map", Value{Map: &Map{Items: []Field{{Name: "a", Value: IntValue(1)}}}}, Value{Map: &Map{Items: []Field{{Name: "a", Value: IntValue(1)}}}}},
+	}
+	for _, c := range cases {
+		if got := Compare(c.a, c.b); got != 0 {
+			t.Errorf("%v: Compare(a, b) = %v, want 0", c.name, got)
+		}
+	}
+}
+
+func TestCompareOrdering(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b Value
+	}{
+		{"bool false < true", BooleanValue(false), BooleanValue(true)},
+		{"int 1 < 2", IntValue(1), IntValue(2)},
+		{"float 1.5 < 2.5", FloatValue(1.5), FloatValue(2.5)},
+		{"string a < b", StringValue("a"), StringValue("b")},
+		{"null < bool", Value{Null: true}, BooleanValue(false)},
+		{"bool < int", BooleanValue(true), IntValue(0)},
+		{"int < float", IntValue(100), FloatValue(0.5)},
+		{"float < string", FloatValue(100), StringValue("")},
+		{"string < list", StringValue("z"), Value{List: &List{}}},
+		{"list < map", Value{List: &List{}}, Value{Map: &Map{}}},
+		{"shorter list < longer list with equal prefix", Value{List: &List{Items: []Value{IntValue(1)}}}, Value{List: &List{Items: []Value{IntValue(1), IntValue(2)}}}},
+	}
+	for _, c := range cases {
+		if got := Compare(c.a, c.b); got >= 0 {
+			t.Errorf("%v: Compare(a, b) = %v, want < 0", c.name, got)
+		}
+		if got := Compare(c.b, c.a); got <= 0 {
+			t.Errorf("%v: Compare(b, a) = %v, want > 0", c.name, got)
+		}
+	}
+}
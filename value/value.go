@@ -84,6 +84,17 @@ func (m *Map) Set(key string, value Value) {
 	m.index = nil // Since the append might have reallocated
 }
 
+// Delete removes key from the map, if present.
+func (m *Map) Delete(key string) {
+	for i := range m.Items {
+		if m.Items[i].Name == key {
+			m.Items = append(m.Items[:i], m.Items[i+1:]...)
+			m.index = nil // Since the removal shifted indices
+			return
+		}
+	}
+}
+
 // StringValue returns s as a scalar string Value.
 func StringValue(s string) Value {
 	s2 := String(s)